@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tomor/netsimulate/events"
+)
+
+// Expect describes the expected outcome of a simulation. After the run
+// completes, runSimulation checks the recorded event log against it and
+// reports a non-zero exit on a mismatch. This turns each numbered simulation
+// into an executable assertion about net/http's behavior instead of a manual
+// demo. Leave it as the zero value to skip assertions entirely.
+type Expect struct {
+	ConnsDialed       int      // connect_done events expected
+	ConnsReused       int      // got_conn_reused events expected
+	RequestsSucceeded int      // request_success events expected
+	Retries           int      // retry events expected (a GetConn fired more than once for the same request)
+	Errors            []string // substrings every observed error must collectively cover
+}
+
+func (e Expect) isZero() bool {
+	return e.ConnsDialed == 0 && e.ConnsReused == 0 && e.RequestsSucceeded == 0 && e.Retries == 0 && len(e.Errors) == 0
+}
+
+// checkExpectations compares the recorded event log against cfg.Expect and
+// returns a description of every mismatch. It's a no-op (nil) if the
+// scenario didn't declare any expectations.
+func checkExpectations(cfg *Config) []string {
+	if cfg.Expect.isZero() {
+		return nil
+	}
+
+	var connsDialed, connsReused, requestsSucceeded, retries int
+	var errs []string
+	for _, e := range events.Snapshot() {
+		switch e.Event {
+		case "connect_done":
+			connsDialed++
+		case "got_conn_reused":
+			connsReused++
+		case "request_success":
+			requestsSucceeded++
+		case "retry":
+			retries++
+		}
+		if e.Err != "" {
+			errs = append(errs, e.Err)
+		}
+	}
+
+	var mismatches []string
+	if cfg.Expect.ConnsDialed != 0 && connsDialed != cfg.Expect.ConnsDialed {
+		mismatches = append(mismatches, fmt.Sprintf("ConnsDialed: expected %d, got %d", cfg.Expect.ConnsDialed, connsDialed))
+	}
+	if cfg.Expect.ConnsReused != 0 && connsReused != cfg.Expect.ConnsReused {
+		mismatches = append(mismatches, fmt.Sprintf("ConnsReused: expected %d, got %d", cfg.Expect.ConnsReused, connsReused))
+	}
+	if cfg.Expect.RequestsSucceeded != 0 && requestsSucceeded != cfg.Expect.RequestsSucceeded {
+		mismatches = append(mismatches, fmt.Sprintf("RequestsSucceeded: expected %d, got %d", cfg.Expect.RequestsSucceeded, requestsSucceeded))
+	}
+	if cfg.Expect.Retries != 0 && retries != cfg.Expect.Retries {
+		mismatches = append(mismatches, fmt.Sprintf("Retries: expected %d, got %d", cfg.Expect.Retries, retries))
+	}
+	for _, want := range cfg.Expect.Errors {
+		if !anyContains(errs, want) {
+			mismatches = append(mismatches, fmt.Sprintf("Errors: expected an error containing %q, got %v", want, errs))
+		}
+	}
+
+	sort.Strings(mismatches)
+	return mismatches
+}
+
+func anyContains(haystack []string, needle string) bool {
+	for _, h := range haystack {
+		if strings.Contains(h, needle) {
+			return true
+		}
+	}
+	return false
+}
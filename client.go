@@ -1,15 +1,23 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"net/http/httptrace"
+	"net/url"
 	"os"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"golang.org/x/net/http2"
+
+	"github.com/tomor/netsimulate/events"
 )
 
 func wait(sec int) {
@@ -26,36 +34,80 @@ func wait(sec int) {
 }
 
 func sendRequest(client *http.Client, num int, cfg Config) {
+	reqID := fmt.Sprintf("req-%d", num)
 	fmt.Printf("\nclient: Sending %d. %s request...\n", num, cfg.ClientRequestMethod)
+	events.Emit("client", "request_start", reqID, 0, nil)
 	var err error
 
-	req, err := http.NewRequest(cfg.ClientRequestMethod, fmt.Sprintf("%s?method=%s&req=%d", cfg.ClientRequestURL, cfg.ClientRequestMethod, num), nil)
-	req = req.WithContext(httptrace.WithClientTrace(req.Context(), getTrace())) // attach the trace to the request context
+	var bodyReader io.Reader
+	if cfg.ClientBody != "" {
+		bodyReader = strings.NewReader(cfg.ClientBody)
+	}
+	req, err := http.NewRequest(cfg.ClientRequestMethod, fmt.Sprintf("%s?method=%s&req=%d", cfg.ClientRequestURL, cfg.ClientRequestMethod, num), bodyReader)
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), getTrace(reqID))) // attach the trace to the request context
 
 	if err != nil {
 		fmt.Printf("client: Error creating request %d. request: %v\n", num, err)
+		events.Emit("client", "request_error", reqID, 0, err)
 		return
 	}
+	if cfg.ClientContentType != "" {
+		req.Header.Set("Content-Type", cfg.ClientContentType)
+	}
+	for k, v := range cfg.ClientHeaders {
+		req.Header.Set(k, v)
+	}
+
 	resp, err := client.Do(req)
 	if err != nil {
 		fmt.Printf("client: Error on %d. request: %v\n", num, err)
+		events.Emit("client", "request_error", reqID, 0, err)
 		return
 	}
 	defer resp.Body.Close()
 
-	body, _ := io.ReadAll(resp.Body)
+	if cfg.ClientReadByteInterval != 0 {
+		resp.Body = newSlowReader(resp.Body, cfg.ClientReadByteInterval)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Printf("client: Error reading %d. request's body: %v (got %d byte(s): %q)\n", num, err, len(body), string(body))
+		events.Emit("client", "request_error", reqID, 0, err)
+		return
+	}
 	fmt.Printf("client: Response from %d. request: status: %s, body: %s\n", num, resp.Status, string(body))
+	events.Emit("client", "request_success", reqID, 0, nil)
+
+	if cfg.ExpectedStatus != 0 && resp.StatusCode != cfg.ExpectedStatus {
+		err := fmt.Errorf("expected status %d, got %d", cfg.ExpectedStatus, resp.StatusCode)
+		fmt.Printf("client: %v\n", err)
+		events.Emit("client", "unexpected_status", reqID, 0, err)
+	}
 }
 
-func getTrace() *httptrace.ClientTrace {
-	// Create a context with tracing
+// getTrace builds an httptrace.ClientTrace that both prints the existing
+// human-readable lines and emits structured events for reqID, so a
+// simulation's Expect block can be checked once the run finishes.
+func getTrace(reqID string) *httptrace.ClientTrace {
+	var connAttempts int32
+
 	trace := &httptrace.ClientTrace{
 		GetConn: func(hostPort string) {
+			if atomic.AddInt32(&connAttempts, 1) > 1 {
+				events.Emit("client", "retry", reqID, 0, nil)
+			}
 			fmt.Printf("client trace: Trying to get a connection for %s\n", hostPort)
+			events.Emit("trace", "get_conn", reqID, 0, nil)
 		},
 		GotConn: func(info httptrace.GotConnInfo) {
 			fmt.Printf("client trace: Got a connection: reused=%v, wasIdle=%v, idleTime=%v\n",
 				info.Reused, info.WasIdle, info.IdleTime)
+			evt := "got_conn_new"
+			if info.Reused {
+				evt = "got_conn_reused"
+			}
+			events.Emit("trace", evt, reqID, 0, nil)
 		},
 		PutIdleConn: func(err error) {
 			if err != nil {
@@ -63,9 +115,11 @@ func getTrace() *httptrace.ClientTrace {
 			} else {
 				fmt.Println("client trace: Connection returned to idle pool")
 			}
+			events.Emit("trace", "put_idle_conn", reqID, 0, err)
 		},
 		ConnectStart: func(network, addr string) {
 			fmt.Printf("client trace: Dialing new connection to %s:%s\n", network, addr)
+			events.Emit("trace", "connect_start", addr, 0, nil)
 		},
 		ConnectDone: func(network, addr string, err error) {
 			if err != nil {
@@ -73,6 +127,7 @@ func getTrace() *httptrace.ClientTrace {
 			} else {
 				fmt.Printf("client trace: Successfully connected to %s:%s\n", network, addr)
 			}
+			events.Emit("trace", "connect_done", addr, 0, err)
 		},
 	}
 	return trace
@@ -98,7 +153,10 @@ func getKeyLogWriter(cfg *Config) io.Writer {
 	return file
 }
 
-func startClient(cfg *Config) {
+// startClient runs cfg's client-side simulation. ctx is checked between
+// requests so a SIGHUP reload (see runClientLoop in main.go) can interrupt a
+// long ReqCount loop without waiting for it to finish naturally.
+func startClient(ctx context.Context, cfg *Config) {
 	keyLogFile := getKeyLogWriter(cfg)
 	if keyLogFile != nil {
 		if file, ok := keyLogFile.(*os.File); ok {
@@ -106,24 +164,52 @@ func startClient(cfg *Config) {
 		}
 	}
 
-	// Create custom transport with idle timeout settings
-	transport := &http.Transport{
-		IdleConnTimeout: cfg.ClientIdleTimeout,
-		MaxConnsPerHost: cfg.ClientMaxConnsPerHost,
-		DialContext: (&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 30 * time.Second,
-		}).DialContext,
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true, // Allows self-signed certificates
-			KeyLogWriter:       keyLogFile,
-		},
-		ForceAttemptHTTP2: cfg.UseHTTP2,
+	var clientTransport http.RoundTripper
+	if cfg.UseHTTP2 && cfg.HTTP2Mode != HTTP2ModeALPN && cfg.HTTP2Mode != "" {
+		// Go's http.Transport can't speak cleartext HTTP/2: hand it off to
+		// http2.Transport with AllowHTTP so it'll go straight to the preface
+		// (prior knowledge) over a plain TCP dial instead of negotiating TLS/ALPN.
+		clientTransport = &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+				return net.Dial(network, addr)
+			},
+		}
+	} else {
+		clientTLS, err := clientTLSConfig(cfg.TLS)
+		if err != nil {
+			fmt.Printf("client: Error building TLS config: %v\n", err)
+			return
+		}
+		clientTLS.KeyLogWriter = keyLogFile
+
+		// Create custom transport with idle timeout settings
+		transport := &http.Transport{
+			IdleConnTimeout:       cfg.ClientIdleTimeout,
+			MaxConnsPerHost:       cfg.ClientMaxConnsPerHost,
+			ResponseHeaderTimeout: cfg.ClientResponseHeaderTimeout,
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSClientConfig:   clientTLS,
+			ForceAttemptHTTP2: cfg.UseHTTP2,
+		}
+
+		if cfg.ClientProxyURL != "" {
+			proxyURL, err := url.Parse(cfg.ClientProxyURL)
+			if err != nil {
+				fmt.Printf("client: Error parsing proxy URL %q: %v\n", cfg.ClientProxyURL, err)
+			} else {
+				transport.Proxy = http.ProxyURL(proxyURL)
+			}
+		}
+		clientTransport = transport
 	}
 
 	// Create an HTTP client with the custom transport
 	client := &http.Client{
-		Transport: transport,
+		Transport: clientTransport,
 		Timeout:   cfg.ClientTimeout,
 	}
 
@@ -131,6 +217,10 @@ func startClient(cfg *Config) {
 	wg.Add(cfg.ReqCount)
 	// Perform GET requests
 	for i := 1; i <= cfg.ReqCount; i++ {
+		if ctx.Err() != nil {
+			wg.Add(-(cfg.ReqCount - i + 1))
+			break
+		}
 		if cfg.ReqInParallel {
 			go func() {
 				sendRequest(client, i, *cfg)
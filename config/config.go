@@ -0,0 +1,42 @@
+// Package config carries the subset of simulation configuration that the
+// server package needs to drive a scenario, decoupled from the root program's
+// CLI-facing Config so the two can evolve independently.
+package config
+
+import (
+	"crypto/tls"
+	"time"
+)
+
+// ServerType selects which server-side behavior a simulation exercises.
+type ServerType int
+
+const (
+	ServerTypeHTTP ServerType = iota
+	ServerTypeRST
+	ServerTypeMultiResponse
+	ServerTypeSlowBody
+)
+
+// Config defines the server-side behaviour for a single simulation run.
+type Config struct {
+	ServerAddress string
+	UseTLS        bool
+
+	ServerType        ServerType
+	ServerIdleTimeout time.Duration
+	TLSConfig         *tls.Config // nil falls back to the hardcoded server.crt/server.key pair
+
+	// ServerTypeHTTP
+	ServerSleepBeforeResponse   time.Duration
+	ServerSleepOnSecond         bool
+	ServerSleepOnSecondDuration time.Duration
+	// ServerTypeRST, ServerTypeMultiResponse
+	ServerSuccessResponseOnFirst bool
+	// ServerTypeMultiResponse
+	ServerMultiCloseConAfter time.Duration
+	// ServerTypeSlowBody
+	ServerBodySize         int           // Number of body bytes to dribble out
+	ServerBodyByteInterval time.Duration // Delay between each body byte written
+	ServerWriteTimeout     time.Duration // http.Server.WriteTimeout
+}
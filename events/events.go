@@ -0,0 +1,64 @@
+// Package events is a small structured, JSON-lines event bus shared by the
+// client and server sides of a simulation. Every event is printed to stdout
+// as it happens (so packet captures and terminal output stay correlated) and
+// also kept in memory so a scenario's expectations can be checked once the
+// run finishes.
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Event is a single structured trace line.
+type Event struct {
+	Time     time.Time `json:"time"`
+	Actor    string    `json:"actor"` // "client", "server", or "trace"
+	Event    string    `json:"event"`
+	ConnID   string    `json:"conn_id,omitempty"`
+	StreamID uint32    `json:"stream_id,omitempty"`
+	Err      string    `json:"error,omitempty"`
+}
+
+var (
+	mu  sync.Mutex
+	log []Event
+)
+
+// Emit records an event and writes it to stdout as a single JSON line.
+func Emit(actor, event, connID string, streamID uint32, err error) {
+	e := Event{Time: time.Now(), Actor: actor, Event: event, ConnID: connID, StreamID: streamID}
+	if err != nil {
+		e.Err = err.Error()
+	}
+
+	mu.Lock()
+	log = append(log, e)
+	mu.Unlock()
+
+	b, marshalErr := json.Marshal(e)
+	if marshalErr != nil {
+		fmt.Println("events: failed to marshal event:", marshalErr)
+		return
+	}
+	fmt.Println(string(b))
+}
+
+// Reset clears the recorded event log. Simulations call this before each
+// run (or reload) so expectations are checked against that run alone.
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	log = nil
+}
+
+// Snapshot returns a copy of every event recorded since the last Reset.
+func Snapshot() []Event {
+	mu.Lock()
+	defer mu.Unlock()
+	out := make([]Event, len(log))
+	copy(out, log)
+	return out
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/tomor/netsimulate/server"
+)
+
+// HTTP2Mode selects how a UseHTTP2 scenario negotiates the protocol.
+type HTTP2Mode string
+
+const (
+	HTTP2ModeALPN     HTTP2Mode = "h2-alpn"             // TLS + ALPN, net/http's built-in behavior (the original UseHTTP2 path)
+	HTTP2ModeH2CPrior HTTP2Mode = "h2c-prior-knowledge" // cleartext, client sends the HTTP/2 preface immediately
+)
+
+// isValidHTTP2Mode reports whether mode is a value parseArguments will accept
+// ("" defers to HTTP2ModeALPN).
+//
+// There's no HTTP2Mode for an HTTP/1.1 Upgrade: h2c client handshake:
+// golang.org/x/net/http2.Transport only ever originates prior-knowledge
+// cleartext HTTP/2 (AllowHTTP skips straight to the preface), so a client
+// mode claiming to drive a real Upgrade handshake would be indistinguishable
+// from HTTP2ModeH2CPrior. startH2CServer's h2c.NewHandler still accepts an
+// Upgrade request on the same listener from any client that does send one.
+func isValidHTTP2Mode(mode string) bool {
+	switch HTTP2Mode(mode) {
+	case "", HTTP2ModeALPN, HTTP2ModeH2CPrior:
+		return true
+	default:
+		return false
+	}
+}
+
+// startH2CServer serves cfg's scenario in cleartext HTTP/2 via
+// server.StartH2C, which wraps the handler in h2c.NewHandler (accepting both
+// a prior-knowledge preface and an HTTP/1.1 Upgrade: h2c request on the same
+// listener, even though this program's own client only ever speaks the
+// former - see isValidHTTP2Mode) and wires it through the same
+// ConnState/Shutdown accounting as every other ServerType family.
+func startH2CServer(cfg *Config) *server.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("h2c: handling %s %s (proto=%s)\n", r.Method, r.URL.Path, r.Proto)
+		fmt.Fprintf(w, "%s request handled over %s", r.Method, r.Proto)
+	})
+
+	fmt.Println("Starting h2c server (" + string(cfg.HTTP2Mode) + ") on " + cfg.ServerAddress)
+	return server.StartH2C(toServerConfig(cfg), mux)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"net"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+const (
+	ServerTypeH2GoAway ServerType = iota + 3
+	ServerTypeH2StreamReset
+	ServerTypeH2FlowControlStall
+)
+
+// startH2SimServer listens for TLS connections with ALPN "h2" and hand-rolls the
+// HTTP/2 frame exchange itself, bypassing net/http entirely so that edge cases
+// (GOAWAY, RST_STREAM, a stuck flow-control window) that Go's server can't be
+// coaxed into producing can be simulated directly.
+func startH2SimServer(cfg *Config) {
+	tlsConfig, err := serverTLSConfig(cfg.TLS)
+	if err != nil {
+		fmt.Println("h2sim: Error building TLS config:", err)
+		return
+	}
+	// The hand-rolled HTTP/2 exchange below only works over ALPN "h2", so make
+	// sure it's offered regardless of what -alpn passed (or didn't).
+	if !containsString(tlsConfig.NextProtos, "h2") {
+		tlsConfig.NextProtos = append(tlsConfig.NextProtos, "h2")
+	}
+
+	listener, err := tls.Listen("tcp", cfg.ServerAddress, tlsConfig)
+	if err != nil {
+		fmt.Println("h2sim: Error starting listener:", err)
+		return
+	}
+	defer listener.Close()
+
+	fmt.Printf("Starting HTTP/2 sim server (%v) on %s\n", cfg.ServerType, cfg.ServerAddress)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			fmt.Println("h2sim: Error accepting connection:", err)
+			continue
+		}
+		go handleH2Conn(conn, cfg)
+	}
+}
+
+// handleH2Conn performs the minimal handshake (client preface + SETTINGS
+// exchange) required before a conforming HTTP/2 client will consider the
+// connection usable, then hands off to the scenario-specific loop.
+func handleH2Conn(conn net.Conn, cfg *Config) {
+	defer conn.Close()
+
+	preface := make([]byte, len(http2.ClientPreface))
+	if _, err := fullRead(conn, preface); err != nil || !bytes.Equal(preface, []byte(http2.ClientPreface)) {
+		fmt.Println("h2sim: Did not receive a valid HTTP/2 client preface:", err)
+		return
+	}
+
+	framer := http2.NewFramer(conn, conn)
+
+	settings := []http2.Setting{{ID: http2.SettingMaxConcurrentStreams, Val: 250}}
+	if cfg.H2InitialWindowSize != 0 {
+		settings = append(settings, http2.Setting{ID: http2.SettingInitialWindowSize, Val: cfg.H2InitialWindowSize})
+	}
+	if err := framer.WriteSettings(settings...); err != nil {
+		fmt.Println("h2sim: Error writing SETTINGS:", err)
+		return
+	}
+
+	switch cfg.ServerType {
+	case ServerTypeH2GoAway:
+		runH2GoAway(framer, conn, cfg)
+	case ServerTypeH2StreamReset:
+		runH2StreamReset(framer, conn, cfg)
+	case ServerTypeH2FlowControlStall:
+		runH2FlowControlStall(framer, conn, cfg)
+	}
+}
+
+// runH2GoAway answers the first cfg.H2GoAwayAfterStreams streams normally,
+// then sends GOAWAY with LastStreamID set to the last stream it answered, so
+// the client's Transport must dial a new connection for anything after that.
+func runH2GoAway(framer *http2.Framer, conn net.Conn, cfg *Config) {
+	var streamsServed int
+	var lastStreamID uint32
+
+	for {
+		streamID, ok := nextRequestStream(framer)
+		if !ok {
+			return
+		}
+		if streamsServed >= cfg.H2GoAwayAfterStreams {
+			fmt.Printf("server: sending GOAWAY after %d stream(s), last-stream-id=%d\n", streamsServed, lastStreamID)
+			framer.WriteGoAway(lastStreamID, http2.ErrCodeNo, []byte("simulated graceful shutdown"))
+			return
+		}
+		writeH2OKResponse(framer, streamID)
+		streamsServed++
+		lastStreamID = streamID
+	}
+}
+
+// runH2StreamReset answers every stream normally except cfg.H2ResetStreamIndex
+// (1-based, matching the client's request numbering), which gets RST_STREAM
+// with cfg.H2ResetErrorCode instead of a response.
+func runH2StreamReset(framer *http2.Framer, conn net.Conn, cfg *Config) {
+	var streamIndex int
+
+	for {
+		streamID, ok := nextRequestStream(framer)
+		if !ok {
+			return
+		}
+		streamIndex++
+		if streamIndex == cfg.H2ResetStreamIndex {
+			fmt.Printf("server: sending RST_STREAM(%d) on stream %d\n", cfg.H2ResetErrorCode, streamID)
+			framer.WriteRSTStream(streamID, http2.ErrCode(cfg.H2ResetErrorCode))
+			continue
+		}
+		writeH2OKResponse(framer, streamID)
+	}
+}
+
+// runH2FlowControlStall advertises the configured (tiny) initial window in
+// its SETTINGS frame and, cheating rather than properly tracking WINDOW_UPDATE
+// bookkeeping, simply stops writing DATA after that many bytes so the client's
+// body read blocks until ClientTimeout fires.
+func runH2FlowControlStall(framer *http2.Framer, conn net.Conn, cfg *Config) {
+	streamID, ok := nextRequestStream(framer)
+	if !ok {
+		return
+	}
+
+	var hbuf bytes.Buffer
+	hpack.NewEncoder(&hbuf).WriteField(hpack.HeaderField{Name: ":status", Value: "200"})
+	framer.WriteHeaders(http2.HeadersFrameParam{StreamID: streamID, BlockFragment: hbuf.Bytes(), EndHeaders: true})
+
+	stall := cfg.H2InitialWindowSize
+	if stall == 0 {
+		stall = 1
+	}
+	fmt.Printf("server: writing %d byte(s) of body on stream %d, then stalling (no further WINDOW_UPDATE handling)\n", stall, streamID)
+	framer.WriteData(streamID, false, bytes.Repeat([]byte{'x'}, int(stall)))
+	// Intentionally never writes END_STREAM or consumes further WINDOW_UPDATE frames.
+	select {}
+}
+
+// nextRequestStream reads frames until it finds a client HEADERS frame that
+// opens a new request, returning its stream ID. It ignores frame types that
+// don't matter for these scenarios (WINDOW_UPDATE, PING, etc.).
+func nextRequestStream(framer *http2.Framer) (uint32, bool) {
+	for {
+		frame, err := framer.ReadFrame()
+		if err != nil {
+			return 0, false
+		}
+		if hf, ok := frame.(*http2.HeadersFrame); ok {
+			return hf.StreamID, true
+		}
+	}
+}
+
+func writeH2OKResponse(framer *http2.Framer, streamID uint32) {
+	var hbuf bytes.Buffer
+	enc := hpack.NewEncoder(&hbuf)
+	enc.WriteField(hpack.HeaderField{Name: ":status", Value: "200"})
+	enc.WriteField(hpack.HeaderField{Name: "content-type", Value: "text/plain"})
+	framer.WriteHeaders(http2.HeadersFrameParam{StreamID: streamID, BlockFragment: hbuf.Bytes(), EndHeaders: true})
+	framer.WriteData(streamID, true, []byte("OK"))
+}
+
+func fullRead(conn net.Conn, buf []byte) (int, error) {
+	total := 0
+	for total < len(buf) {
+		n, err := conn.Read(buf[total:])
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
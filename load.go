@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// loadResult is what one worker request reports back to the collector.
+type loadResult struct {
+	latency time.Duration
+	status  int
+	bytes   int64
+	errKind string // "", "dial", "tls", "read", "status"
+}
+
+// runLoad drives cfg.LoadConcurrency workers against cfg.ClientRequestURL for
+// up to cfg.LoadDuration (or cfg.LoadRequests total requests, whichever comes
+// first), optionally throttled to cfg.LoadQPS, and prints a latency/error
+// summary. It's an ad hoc overlay on top of whichever ServerType -sim
+// selected, rather than a simulation in its own right. ctx cancellation stops
+// the run early, the same as hitting the deadline, so a SIGHUP reload (see
+// runClientLoop in main.go) can swap in a new Config without waiting it out.
+func runLoad(ctx context.Context, cfg *Config) {
+	clientTLS, err := clientTLSConfig(cfg.TLS)
+	if err != nil {
+		fmt.Printf("load: Error building TLS config: %v\n", err)
+		return
+	}
+	transport := &http.Transport{
+		DisableKeepAlives: cfg.LoadDisableKeepalive,
+		TLSClientConfig:   clientTLS,
+	}
+	client := &http.Client{Transport: transport, Timeout: cfg.ClientTimeout}
+
+	var throttle <-chan time.Time
+	if cfg.LoadQPS > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / float64(cfg.LoadQPS)))
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+
+	var deadline <-chan time.Time
+	if cfg.LoadDuration > 0 {
+		timer := time.NewTimer(cfg.LoadDuration)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	var sent int32
+	var mu sync.Mutex
+	var stop bool
+	shouldSend := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if stop {
+			return false
+		}
+		if cfg.LoadRequests > 0 && int(sent) >= cfg.LoadRequests {
+			return false
+		}
+		sent++
+		return true
+	}
+
+	results := make(chan loadResult, cfg.LoadConcurrency*2)
+
+	var wg sync.WaitGroup
+	wg.Add(cfg.LoadConcurrency)
+	for w := 0; w < cfg.LoadConcurrency; w++ {
+		go func() {
+			defer wg.Done()
+			for {
+				if throttle != nil {
+					<-throttle
+				}
+				if !shouldSend() {
+					return
+				}
+				results <- doLoadRequest(client, cfg)
+			}
+		}()
+	}
+
+	go func() {
+		select {
+		case <-deadline:
+		case <-ctx.Done():
+		}
+		mu.Lock()
+		stop = true
+		mu.Unlock()
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var collected []loadResult
+	for r := range results {
+		collected = append(collected, r)
+	}
+
+	printLoadSummary(collected)
+}
+
+func doLoadRequest(client *http.Client, cfg *Config) loadResult {
+	start := time.Now()
+
+	req, err := http.NewRequest(cfg.ClientRequestMethod, cfg.ClientRequestURL, nil)
+	if err != nil {
+		return loadResult{latency: time.Since(start), errKind: "dial"}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// client.Do always wraps in *url.Error, so check against the
+		// unwrapped chain rather than the top-level error's concrete type.
+		kind := "dial"
+		if isTLSError(err) {
+			kind = "tls"
+		} else {
+			var opErr *net.OpError
+			if errors.As(err, &opErr) {
+				kind = "dial"
+			}
+		}
+		return loadResult{latency: time.Since(start), errKind: kind}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	latency := time.Since(start)
+	if err != nil {
+		return loadResult{latency: latency, status: resp.StatusCode, errKind: "read"}
+	}
+
+	r := loadResult{latency: latency, status: resp.StatusCode, bytes: int64(len(body))}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		r.errKind = "status"
+	}
+	return r
+}
+
+// isTLSError reports whether err (or anything it wraps, e.g. inside the
+// *url.Error client.Do returns) is a TLS handshake or certificate-validation
+// failure.
+func isTLSError(err error) bool {
+	var recordErr tls.RecordHeaderError
+	if errors.As(err, &recordErr) {
+		return true
+	}
+	var certVerifyErr *tls.CertificateVerificationError
+	if errors.As(err, &certVerifyErr) {
+		return true
+	}
+	var unknownAuthErr x509.UnknownAuthorityError
+	if errors.As(err, &unknownAuthErr) {
+		return true
+	}
+	var certInvalidErr x509.CertificateInvalidError
+	if errors.As(err, &certInvalidErr) {
+		return true
+	}
+	var hostnameErr x509.HostnameError
+	if errors.As(err, &hostnameErr) {
+		return true
+	}
+	return false
+}
+
+// printLoadSummary reports latency percentiles, throughput, and errors
+// bucketed by kind, mirroring the detail the non-load simulations get from
+// httptrace but aggregated across every worker.
+func printLoadSummary(results []loadResult) {
+	fmt.Println("load: summary:")
+	fmt.Printf("  Requests completed: %d\n", len(results))
+	if len(results) == 0 {
+		return
+	}
+
+	latencies := make([]time.Duration, len(results))
+	var totalBytes int64
+	errKinds := map[string]int{}
+	for i, r := range results {
+		latencies[i] = r.latency
+		totalBytes += r.bytes
+		if r.errKind != "" {
+			errKinds[r.errKind]++
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	fmt.Printf("  Latency p50: %s, p90: %s, p99: %s\n",
+		percentile(latencies, 0.50), percentile(latencies, 0.90), percentile(latencies, 0.99))
+	fmt.Printf("  Bytes transferred: %d\n", totalBytes)
+	if len(errKinds) == 0 {
+		fmt.Println("  Errors: none")
+		return
+	}
+	fmt.Println("  Errors by kind:")
+	for _, kind := range []string{"dial", "tls", "read", "status"} {
+		if n := errKinds[kind]; n > 0 {
+			fmt.Printf("    %-6s %d\n", kind, n)
+		}
+	}
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(p * float64(len(sorted)))
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"flag"
 	"fmt"
 	"os"
@@ -8,6 +10,27 @@ import (
 	"sync"
 	"syscall"
 	"time"
+
+	"github.com/tomor/netsimulate/config"
+	"github.com/tomor/netsimulate/events"
+	"github.com/tomor/netsimulate/server"
+)
+
+const (
+	srvIP   = "127.0.0.1"
+	port    = "8080"
+	portTLS = "8443"
+
+	// originAddr/originAddrTLS are the fixture origins startProxyServer spins
+	// up for the forward-proxy scenarios to relay to, so they don't depend on
+	// live internet access to a hardcoded public domain (see proxy.go).
+	originAddr    = srvIP + ":8090"
+	originAddrTLS = srvIP + ":8491"
+)
+
+var (
+	activeServerMu sync.Mutex
+	activeServer   *server.Server
 )
 
 func parseArguments() (*Config, error) {
@@ -16,7 +39,26 @@ func parseArguments() (*Config, error) {
 	flag.StringVar(&cfg.ID, "sim", "", "Simulation scenario ID (e.g., '01')") // TODO make sim positional argument (mandatory)
 	flag.BoolVar(&cfg.UseTLS, "tls", false, "Use TLS for the selected scenario (not supported by all simulations)")
 	flag.StringVar(&cfg.KeyLogFilePath, "keylog", "", "File path where the client TLS session keys will be written")
-	flag.StringVar(&cfg.ClientRequestMethod, "method", "GET", "Ad hoc change of HTTP request method (GET, POST, DELETE, HEAD)")
+	flag.StringVar(&cfg.ClientRequestMethod, "method", "", "Ad hoc change of HTTP request method (GET, POST, DELETE, HEAD); overrides whatever the scenario sets")
+	flag.StringVar(&cfg.ScenariosDir, "scenarios", "", "Directory of *.yaml scenario files to merge with the built-in simulations")
+	flag.StringVar(&cfg.ConfigFilePath, "config", "", "Single YAML or JSON file defining one or more scenarios (request/response/execution schema) to merge with the built-in simulations")
+	var h2mode string
+	flag.StringVar(&h2mode, "h2mode", "", "HTTP/2 negotiation mode for UseHTTP2 scenarios: h2-alpn (default), h2c-prior-knowledge")
+	flag.IntVar(&cfg.LoadConcurrency, "concurrency", 0, "Load mode: number of concurrent workers hitting the selected scenario (0 disables load mode)")
+	flag.DurationVar(&cfg.LoadDuration, "duration", 0, "Load mode: stop after this long (0 means rely on -requests)")
+	flag.IntVar(&cfg.LoadQPS, "qps", 0, "Load mode: shared throttle across all workers, in requests/sec (0 means unthrottled)")
+	flag.IntVar(&cfg.LoadRequests, "requests", 0, "Load mode: stop after this many total requests (0 means rely on -duration)")
+	flag.BoolVar(&cfg.LoadDisableKeepalive, "disable-keepalive", false, "Load mode: disable HTTP keep-alives on load workers")
+	flag.StringVar(&cfg.TLS.CertFile, "cert", "", "TLS: certificate file (defaults to server.crt / no client cert)")
+	flag.StringVar(&cfg.TLS.KeyFile, "key", "", "TLS: private key file matching -cert")
+	flag.StringVar(&cfg.TLS.CACertFile, "cacert", "", "TLS: CA bundle used to verify the peer's certificate")
+	var tlsMin, tlsMax, ciphers, alpn, clientAuth string
+	flag.StringVar(&tlsMin, "tls-min", "", "TLS: minimum version (1.0, 1.1, 1.2, 1.3)")
+	flag.StringVar(&tlsMax, "tls-max", "", "TLS: maximum version (1.0, 1.1, 1.2, 1.3)")
+	flag.StringVar(&ciphers, "ciphers", "", "TLS: comma-separated cipher suite names, e.g. TLS_RSA_WITH_AES_128_CBC_SHA")
+	flag.StringVar(&alpn, "alpn", "", "TLS: comma-separated ALPN protocol list, e.g. h2,http/1.1")
+	flag.BoolVar(&cfg.TLS.InsecureSkipVerify, "insecure-skip-verify", true, "TLS: client skips verifying the server's certificate")
+	flag.StringVar(&clientAuth, "client-auth", "", "TLS: server's client-certificate policy (none, request, require, verify-if-given, require-and-verify)")
 	flag.Usage = displayHelp
 	flag.Parse()
 
@@ -28,6 +70,26 @@ func parseArguments() (*Config, error) {
 		return nil, fmt.Errorf("invalid method: %s. Allowed methods are GET, POST, DELETE, HEAD", cfg.ClientRequestMethod)
 	}
 
+	if !isValidHTTP2Mode(h2mode) {
+		return nil, fmt.Errorf("invalid h2mode: %s. Allowed values are h2-alpn, h2c-prior-knowledge", h2mode)
+	}
+	cfg.HTTP2Mode = HTTP2Mode(h2mode)
+
+	var err error
+	if cfg.TLS.MinVersion, err = parseTLSVersion(tlsMin); err != nil {
+		return nil, fmt.Errorf("-tls-min: %w", err)
+	}
+	if cfg.TLS.MaxVersion, err = parseTLSVersion(tlsMax); err != nil {
+		return nil, fmt.Errorf("-tls-max: %w", err)
+	}
+	if cfg.TLS.CipherSuites, err = parseCipherSuites(ciphers); err != nil {
+		return nil, fmt.Errorf("-ciphers: %w", err)
+	}
+	cfg.TLS.ALPNProtocols = parseALPN(alpn)
+	if cfg.TLS.ClientAuth, err = parseClientAuth(clientAuth); err != nil {
+		return nil, fmt.Errorf("-client-auth: %w", err)
+	}
+
 	return cfg, nil
 }
 
@@ -58,19 +120,51 @@ func loadConfiguration(argsCfg *Config) *Config {
 	if argsCfg.ClientRequestMethod != "" {
 		cfg.ClientRequestMethod = argsCfg.ClientRequestMethod
 	}
+	if cfg.ClientRequestMethod == "" {
+		cfg.ClientRequestMethod = "GET"
+	}
 	cfg.UseTLS = argsCfg.UseTLS
+	if argsCfg.HTTP2Mode != "" {
+		cfg.HTTP2Mode = argsCfg.HTTP2Mode
+	}
 	if cfg.UseHTTP2 {
-		cfg.UseTLS = true // http2 forces https
+		if cfg.HTTP2Mode == "" {
+			cfg.HTTP2Mode = HTTP2ModeALPN
+		}
+		if cfg.HTTP2Mode == HTTP2ModeALPN {
+			cfg.UseTLS = true // ALPN-negotiated http2 forces https; the h2c modes stay cleartext
+		}
 	}
 	cfg.KeyLogFilePath = argsCfg.KeyLogFilePath
+	if argsCfg.LoadConcurrency != 0 {
+		cfg.LoadConcurrency = argsCfg.LoadConcurrency
+	}
+	if argsCfg.LoadDuration != 0 {
+		cfg.LoadDuration = argsCfg.LoadDuration
+	}
+	if argsCfg.LoadQPS != 0 {
+		cfg.LoadQPS = argsCfg.LoadQPS
+	}
+	if argsCfg.LoadRequests != 0 {
+		cfg.LoadRequests = argsCfg.LoadRequests
+	}
+	if argsCfg.LoadDisableKeepalive {
+		cfg.LoadDisableKeepalive = true
+	}
+	cfg.TLS = argsCfg.TLS
 
 	// Set URLs based on HTTPS mode
 	if cfg.UseTLS {
 		cfg.ServerAddress = srvIP + ":" + portTLS
-		cfg.ClientRequestURL = "https://" + cfg.ServerAddress
 	} else {
 		cfg.ServerAddress = srvIP + ":" + port
-		cfg.ClientRequestURL = "http://" + cfg.ServerAddress
+	}
+	if cfg.ServerType == ServerTypeProxy {
+		cfg.ClientProxyURL = "http://" + cfg.ServerAddress
+	} else if cfg.UseTLS {
+		cfg.ClientRequestURL = "https://" + cfg.ServerAddress + cfg.ClientRequestPath
+	} else {
+		cfg.ClientRequestURL = "http://" + cfg.ServerAddress + cfg.ClientRequestPath
 	}
 
 	return cfg
@@ -91,6 +185,17 @@ func displayHelp() {
 	fmt.Println("  -tls        (optional)  Ad hoc change to HTTPS for the selected simulation (not supported by all simulations)")
 	fmt.Println("  -keylog     (optional)  File path where the client TLS session keys will be written")
 	fmt.Println("  -method     (optional)  Ad hoc change of HTTP request method (GET, POST, DELETE, HEAD)")
+	fmt.Println("  -concurrency (optional) Load mode: number of concurrent workers (0 disables load mode)")
+	fmt.Println("  -duration   (optional)  Load mode: stop after this long, e.g. 30s")
+	fmt.Println("  -qps        (optional)  Load mode: shared throttle across workers, in requests/sec")
+	fmt.Println("  -requests   (optional)  Load mode: stop after this many total requests")
+	fmt.Println("  -cert, -key (optional)  TLS: certificate/key pair to serve or present (defaults to server.crt/server.key)")
+	fmt.Println("  -cacert     (optional)  TLS: CA bundle used to verify the peer's certificate")
+	fmt.Println("  -tls-min, -tls-max (optional) TLS: version range, e.g. -tls-min 1.2")
+	fmt.Println("  -ciphers    (optional)  TLS: comma-separated cipher suite names")
+	fmt.Println("  -alpn       (optional)  TLS: comma-separated ALPN protocol list, e.g. h2,http/1.1")
+	fmt.Println("  -insecure-skip-verify   TLS: client skips verifying the server's certificate (default true)")
+	fmt.Println("  -client-auth (optional) TLS: server's client-certificate policy")
 	fmt.Println("  -h          Show help and exit")
 	fmt.Println()
 
@@ -108,33 +213,167 @@ func httpsInfo(cfg *Config) string {
 	return "(no TLS)"
 }
 
-// runSimulation sets up and executes the simulation by starting the server and client.
-func runSimulation(cfg *Config) {
-	var wg sync.WaitGroup
+// startServer dispatches to the right server implementation for cfg.ServerType.
+// The HTTP/2 simulations hand-roll their own framer server (see h2sim.go); the
+// original plain HTTP/TCP scenarios are handled by the server package.
+func startServer(cfg *Config) {
+	switch {
+	case cfg.ServerType == ServerTypeH2GoAway || cfg.ServerType == ServerTypeH2StreamReset || cfg.ServerType == ServerTypeH2FlowControlStall:
+		startH2SimServer(cfg)
+	case cfg.ServerType == ServerTypeProxy:
+		startProxyServer(cfg)
+	case cfg.UseHTTP2 && cfg.HTTP2Mode != HTTP2ModeALPN && cfg.HTTP2Mode != "":
+		srv := startH2CServer(cfg)
+		activeServerMu.Lock()
+		activeServer = srv
+		activeServerMu.Unlock()
+	default:
+		srv := server.Start(toServerConfig(cfg))
+		activeServerMu.Lock()
+		activeServer = srv
+		activeServerMu.Unlock()
+	}
+}
+
+// shutdownServer gracefully drains the active server (if the scenario's
+// ServerType supports it) and prints its connection-lifecycle summary.
+func shutdownServer() {
+	activeServerMu.Lock()
+	srv := activeServer
+	activeServerMu.Unlock()
+	if srv == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		fmt.Println("server: error during shutdown:", err)
+	}
+}
 
+// toServerConfigType maps the root package's ServerType (which also covers
+// scenarios the server package doesn't know about, like the H2 sim and proxy
+// servers) onto the config package's narrower ServerType. The two consts
+// aren't numerically aligned, so this has to be an explicit switch rather
+// than a raw conversion.
+func toServerConfigType(t ServerType) config.ServerType {
+	switch t {
+	case ServerTypeRST:
+		return config.ServerTypeRST
+	case ServerTypeMultiResponse:
+		return config.ServerTypeMultiResponse
+	case ServerTypeSlowBody:
+		return config.ServerTypeSlowBody
+	default:
+		return config.ServerTypeHTTP
+	}
+}
+
+// toServerConfig adapts the root Config to the server package's narrower Config.
+func toServerConfig(cfg *Config) *config.Config {
+	var tlsConfig *tls.Config
+	if cfg.UseTLS {
+		var err error
+		tlsConfig, err = serverTLSConfig(cfg.TLS)
+		if err != nil {
+			fmt.Printf("Error building server TLS config: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	return &config.Config{
+		ServerAddress:                cfg.ServerAddress,
+		UseTLS:                       cfg.UseTLS,
+		TLSConfig:                    tlsConfig,
+		ServerType:                   toServerConfigType(cfg.ServerType),
+		ServerIdleTimeout:            cfg.ServerIdleTimeout,
+		ServerSleepBeforeResponse:    cfg.ServerSleepBeforeResponse,
+		ServerSleepOnSecond:          cfg.ServerSleepOnSecond,
+		ServerSleepOnSecondDuration:  cfg.ServerSleepOnSecondDuration,
+		ServerSuccessResponseOnFirst: cfg.ServerSuccessResponseOnFirst,
+		ServerMultiCloseConAfter:     cfg.ServerMultiCloseConAfter,
+		ServerBodySize:               cfg.ServerBodySize,
+		ServerBodyByteInterval:       cfg.ServerBodyByteInterval,
+		ServerWriteTimeout:           cfg.ServerWriteTimeout,
+	}
+}
+
+// runClientLoop drives (and, on SIGHUP, redrives) the client/load side of
+// the simulation against the already-running server. The listener started
+// by startServer is never touched here, so a reload doesn't drop it: each
+// generation gets its own cancellable context, and a SIGHUP cancels the
+// current one, waits for it to drain, reloads cfg from argsCfg.ConfigFilePath
+// (if one was given), and starts a fresh generation in its place.
+func runClientLoop(argsCfg *Config, cfg *Config) {
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
+	defer signal.Stop(reloadChan)
+
+	for {
+		ctx, cancel := context.WithCancel(context.Background())
+		genDone := make(chan struct{})
+		go func(cfg *Config) {
+			defer close(genDone)
+			if cfg.LoadConcurrency > 0 {
+				runLoad(ctx, cfg)
+			} else {
+				startClient(ctx, cfg)
+			}
+		}(cfg)
+
+		select {
+		case <-genDone:
+			cancel()
+			return
+		case <-reloadChan:
+			fmt.Println("program: SIGHUP received, reloading configuration...")
+			cancel()
+			<-genDone
+
+			if argsCfg.ConfigFilePath == "" {
+				fmt.Println("program: no -config file to reload from, resuming with the same configuration")
+				continue
+			}
+			reloaded, err := loadConfigFile(argsCfg.ConfigFilePath)
+			if err != nil {
+				fmt.Printf("program: error reloading -config %q: %v, resuming with the same configuration\n", argsCfg.ConfigFilePath, err)
+				continue
+			}
+			fresh, found := reloaded.get(cfg.ID)
+			if !found {
+				fmt.Printf("program: %q no longer defines scenario %s, resuming with the same configuration\n", argsCfg.ConfigFilePath, cfg.ID)
+				continue
+			}
+			// Carry over what the listener was actually started with; only
+			// the reloadable client-facing knobs come from the file.
+			fresh.ServerAddress = cfg.ServerAddress
+			fresh.ClientRequestURL = cfg.ClientRequestURL
+			fresh.ClientProxyURL = cfg.ClientProxyURL
+			cfg = fresh
+			fmt.Println("program: reloaded")
+		}
+	}
+}
+
+// runSimulation sets up and executes the simulation by starting the server and client.
+func runSimulation(argsCfg *Config, cfg *Config) {
+	events.Reset()
 	go startServer(cfg)
 
-	wg.Add(1)
+	// wait a bit for the server to be ready
+	time.Sleep(1 * time.Second)
+
+	doneChan := make(chan struct{})
 	go func() {
-		defer wg.Done()
-		// wait a bit for the server to be ready
-		time.Sleep(1 * time.Second)
-		startClient(cfg)
+		defer close(doneChan)
+		runClientLoop(argsCfg, cfg)
 	}()
 
 	// Create a channel to listen for interrupt signals
 	stopChan := make(chan os.Signal, 1)
 	signal.Notify(stopChan, syscall.SIGINT, syscall.SIGTERM) // press Ctrl+C to stop
 
-	// Create a done channel to signal when the client finishes
-	doneChan := make(chan struct{})
-
-	// Start a goroutine to wait for the WaitGroup to finish
-	go func() {
-		wg.Wait()
-		close(doneChan)
-	}()
-
 	select {
 	case <-stopChan: // Handle Ctrl+C or termination signal
 		fmt.Printf("\nprogram: shutdown signal received. Exiting...\n")
@@ -142,7 +381,23 @@ func runSimulation(cfg *Config) {
 		fmt.Printf("\nprogram: all tasks completed successfully. Exiting...\n")
 	}
 
+	shutdownServer()
+
 	fmt.Println("Simulation stopped.")
+
+	// Expect is written against the single-client event log; load mode drives
+	// cfg.LoadConcurrency workers through doLoadRequest instead, which never
+	// populates that log, so there's nothing meaningful to assert against.
+	if cfg.LoadConcurrency > 0 {
+		return
+	}
+	if mismatches := checkExpectations(cfg); len(mismatches) > 0 {
+		fmt.Println("assertion failures:")
+		for _, m := range mismatches {
+			fmt.Println("  -", m)
+		}
+		os.Exit(1)
+	}
 }
 
 func main() {
@@ -152,7 +407,23 @@ func main() {
 		fmt.Fprintf(os.Stderr, "\nError: %v\n", err)
 		return
 	}
+	if args.ScenariosDir != "" {
+		loaded, err := loadScenarios(args.ScenariosDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading -scenarios %q: %v\n", args.ScenariosDir, err)
+			os.Exit(1)
+		}
+		simulations = mergeScenarios(simulations, loaded)
+	}
+	if args.ConfigFilePath != "" {
+		loaded, err := loadConfigFile(args.ConfigFilePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading -config %q: %v\n", args.ConfigFilePath, err)
+			os.Exit(1)
+		}
+		simulations = mergeScenarios(simulations, loaded)
+	}
 	cfg := loadConfiguration(args)
 	cfg.print()
-	runSimulation(cfg)
+	runSimulation(args, cfg)
 }
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const ServerTypeProxy ServerType = iota + 6
+
+// startOriginFixtures starts the plain-HTTP and TLS origin servers the proxy
+// scenarios relay to (originAddr, originAddrTLS), so they demonstrate the
+// proxy's behavior against a local, always-reachable origin instead of a
+// live internet dependency on a hardcoded public domain.
+func startOriginFixtures() {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "origin: handled %s %s", r.Method, r.URL.Path)
+	})
+
+	go func() {
+		srv := &http.Server{Addr: originAddr, Handler: handler}
+		fmt.Println("Starting proxy origin fixture on " + originAddr)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fmt.Println("proxy: error starting origin fixture:", err)
+		}
+	}()
+
+	go func() {
+		srv := &http.Server{Addr: originAddrTLS, Handler: handler}
+		fmt.Println("Starting proxy origin fixture (TLS) on " + originAddrTLS)
+		if err := srv.ListenAndServeTLS("server.crt", "server.key"); err != nil && err != http.ErrServerClosed {
+			fmt.Println("proxy: error starting TLS origin fixture:", err)
+		}
+	}()
+}
+
+// startProxyServer stands up a plain HTTP forward proxy on cfg.ServerAddress,
+// supporting both "GET http://..." (relayed origin requests) and
+// "CONNECT host:port" (tunneled, e.g. for HTTPS), plus the configured
+// failure injections. It also starts the local origin fixtures scenarios 30
+// and 31 relay/tunnel to (see startOriginFixtures).
+func startProxyServer(cfg *Config) {
+	startOriginFixtures()
+
+	var requestCount int32
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if cfg.ProxyInjectAuthRequired && r.Header.Get("Proxy-Authorization") == "" {
+			fmt.Println("proxy: rejecting request without Proxy-Authorization")
+			w.Header().Set("Proxy-Authenticate", `Basic realm="netsimulate"`)
+			http.Error(w, "Proxy Authentication Required", http.StatusProxyAuthRequired)
+			return
+		}
+
+		n := atomic.AddInt32(&requestCount, 1)
+		if cfg.ProxyReturn502AfterN > 0 && int(n) > cfg.ProxyReturn502AfterN {
+			fmt.Printf("proxy: returning 502 for request %d (> ProxyReturn502AfterN=%d)\n", n, cfg.ProxyReturn502AfterN)
+			http.Error(w, "Bad Gateway", http.StatusBadGateway)
+			return
+		}
+
+		if r.Method == http.MethodConnect {
+			handleConnectTunnel(w, r, cfg)
+			return
+		}
+		proxyPlainRequest(w, r)
+	}
+
+	srv := &http.Server{
+		Addr:        cfg.ServerAddress,
+		Handler:     http.HandlerFunc(handler),
+		IdleTimeout: cfg.ServerIdleTimeout,
+	}
+
+	fmt.Println("Starting forward proxy on " + cfg.ServerAddress)
+	if err := srv.ListenAndServe(); err != nil {
+		fmt.Println("Error starting proxy:", err)
+	}
+}
+
+// proxyPlainRequest relays a plain absolute-URL request to its origin and
+// copies the response back to the client unmodified.
+func proxyPlainRequest(w http.ResponseWriter, r *http.Request) {
+	outReq := r.Clone(r.Context())
+	outReq.RequestURI = ""
+
+	resp, err := http.DefaultTransport.RoundTrip(outReq)
+	if err != nil {
+		http.Error(w, "Bad Gateway: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	for k, values := range resp.Header {
+		for _, v := range values {
+			w.Header().Add(k, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// handleConnectTunnel dials the CONNECT target, confirms the tunnel with a
+// 200, then splices bytes between client and target. If ProxyCloseTunnelAfter
+// is set, the tunnel is torn down after that timer regardless of traffic,
+// mirroring the ServerMultiCloseConAfter "answer then go away" semantics used
+// for origin connections.
+func handleConnectTunnel(w http.ResponseWriter, r *http.Request, cfg *Config) {
+	target, err := net.DialTimeout("tcp", r.Host, 10*time.Second)
+	if err != nil {
+		http.Error(w, "Bad Gateway: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		target.Close()
+		http.Error(w, "proxy does not support hijacking", http.StatusInternalServerError)
+		return
+	}
+	client, _, err := hijacker.Hijack()
+	if err != nil {
+		target.Close()
+		return
+	}
+
+	fmt.Fprint(client, "HTTP/1.1 200 Connection Established\r\n\r\n")
+	fmt.Printf("proxy: tunnel established to %s\n", r.Host)
+
+	if cfg.ProxyCloseTunnelAfter != 0 {
+		go func() {
+			time.Sleep(cfg.ProxyCloseTunnelAfter)
+			fmt.Printf("proxy: closing tunnel to %s after %s\n", r.Host, cfg.ProxyCloseTunnelAfter)
+			client.Close()
+			target.Close()
+		}()
+	}
+
+	done := make(chan struct{}, 2)
+	go func() { io.Copy(target, client); done <- struct{}{} }()
+	go func() { io.Copy(client, target); done <- struct{}{} }()
+	<-done
+
+	target.Close()
+	client.Close()
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadScenarios reads every *.yaml file in dir and decodes it into a Config,
+// letting users add simulations without recompiling. Each file is one
+// scenario; the filename isn't significant, only the ID field inside it.
+func loadScenarios(dir string) (Simulations, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("glob %s: %w", dir, err)
+	}
+
+	var loaded Simulations
+	for _, path := range matches {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", path, err)
+		}
+
+		var cfg Config
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+		if cfg.ID == "" {
+			return nil, fmt.Errorf("%s: scenario is missing an ID", path)
+		}
+
+		loaded = append(loaded, cfg)
+		fmt.Printf("scenarios: loaded %s from %s\n", cfg.ID, path)
+	}
+
+	return loaded, nil
+}
+
+// mergeScenarios overlays extra onto base, with extra taking precedence when
+// an ID collides with a built-in simulation (so a user can override "01"
+// without forking the binary).
+func mergeScenarios(base, extra Simulations) Simulations {
+	merged := make(Simulations, 0, len(base)+len(extra))
+	merged = append(merged, base...)
+
+	for _, e := range extra {
+		replaced := false
+		for i, b := range merged {
+			if b.ID == e.ID {
+				merged[i] = e
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			merged = append(merged, e)
+		}
+	}
+
+	return merged
+}
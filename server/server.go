@@ -1,22 +1,130 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"os"
+	"sync"
 	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/tomor/netsimulate/config"
+	"github.com/tomor/netsimulate/events"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
+// Server owns the listener for a simulation run and tracks connection
+// lifecycle (via http.Server.ConnState on the HTTP path, and an equivalent
+// counter map keyed by RemoteAddr on the raw TCP path) so it can both shut
+// down cleanly and print a server-side summary symmetric to the client's
+// httptrace output.
+type Server struct {
+	cfg *config.Config
+
+	httpServer *http.Server
+	listener   net.Listener
+	wg         sync.WaitGroup // in-flight handleConnection goroutines, TCP path only
+
+	mu            sync.Mutex
+	connCounts    map[http.ConnState]int
+	idle          map[string]struct{} // RemoteAddr currently idle/open, used to derive peak idle
+	peakIdle      int
+	connsAccepted int32
+	rstSent       int32
+	bytesWritten  int64
+}
+
+func newServer(cfg *config.Config) *Server {
+	return &Server{
+		cfg:        cfg,
+		connCounts: make(map[http.ConnState]int),
+		idle:       make(map[string]struct{}),
+	}
+}
+
+// ConnState is wired as http.Server.ConnState: it counts every state
+// transition and tracks the peak number of simultaneously idle connections.
+func (s *Server) ConnState(conn net.Conn, state http.ConnState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.connCounts[state]++
+	switch state {
+	case http.StateNew:
+		s.connsAccepted++
+	case http.StateIdle:
+		s.idle[conn.RemoteAddr().String()] = struct{}{}
+		if len(s.idle) > s.peakIdle {
+			s.peakIdle = len(s.idle)
+		}
+	case http.StateActive, http.StateClosed, http.StateHijacked:
+		delete(s.idle, conn.RemoteAddr().String())
+	}
+}
+
+func (s *Server) trackAccepted(remoteAddr string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connsAccepted++
+	s.idle[remoteAddr] = struct{}{}
+	if len(s.idle) > s.peakIdle {
+		s.peakIdle = len(s.idle)
+	}
+}
+
+func (s *Server) trackClosed(remoteAddr string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.idle, remoteAddr)
+}
+
+func (s *Server) trackRST() {
+	atomic.AddInt32(&s.rstSent, 1)
+}
+
+func (s *Server) trackBytesWritten(n int) {
+	atomic.AddInt64(&s.bytesWritten, int64(n))
+}
+
+// Shutdown drains the server gracefully: for the HTTP path it delegates to
+// http.Server.Shutdown (letting in-flight requests finish); for the raw TCP
+// path it closes the listener and waits for every in-flight
+// handleConnection goroutine to finish. Either way, it finishes by printing
+// the server-side summary.
+func (s *Server) Shutdown(ctx context.Context) error {
+	var err error
+	if s.httpServer != nil {
+		err = s.httpServer.Shutdown(ctx)
+	} else if s.listener != nil {
+		err = s.listener.Close()
+	}
+	s.wg.Wait()
+	s.printSummary()
+	return err
+}
+
+func (s *Server) printSummary() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Println("server: summary:")
+	fmt.Printf("  Connections accepted: %d\n", s.connsAccepted)
+	fmt.Printf("  Peak idle connections: %d\n", s.peakIdle)
+	fmt.Printf("  RSTs sent:             %d\n", s.rstSent)
+	fmt.Printf("  Bytes written:         %d\n", s.bytesWritten)
+}
+
 func newHandler(cfg *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		queryNum := r.FormValue("req")
 		fmt.Printf("server: handling request %s num %s\n", r.Method, queryNum)
+		events.Emit("server", "request_handled", r.RemoteAddr, 0, nil)
 
 		// Handle server sleep based on the request number
 		if cfg.ServerSleepOnSecond && queryNum == "2" {
@@ -29,6 +137,11 @@ func newHandler(cfg *config.Config) http.HandlerFunc {
 			time.Sleep(cfg.ServerSleepBeforeResponse)
 		}
 
+		if cfg.ServerType == config.ServerTypeSlowBody {
+			writeSlowBody(w, cfg)
+			return
+		}
+
 		// Handle different request methods
 		switch r.Method {
 		case http.MethodGet:
@@ -49,56 +162,90 @@ func newHandler(cfg *config.Config) http.HandlerFunc {
 	}
 }
 
-func startHTTPServer(cfg *config.Config) {
-	http.HandleFunc("/", newHandler(cfg)) // Endpoint to handle requests
+// writeSlowBody sends the response headers immediately, then dribbles the
+// body out one byte at a time with a delay between each, flushing after
+// every byte. It exercises the same partial-write edge case as sendMulti's
+// abrupt close, but on the HTTP path where ServerWriteTimeout can cut it off.
+func writeSlowBody(w http.ResponseWriter, cfg *config.Config) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Printf("server: writing %d byte(s) of body, %s apart\n", cfg.ServerBodySize, cfg.ServerBodyByteInterval)
+	for i := 0; i < cfg.ServerBodySize; i++ {
+		if _, err := w.Write([]byte{'x'}); err != nil {
+			fmt.Println("server: error writing slow body byte:", err)
+			return
+		}
+		flusher.Flush()
+		time.Sleep(cfg.ServerBodyByteInterval)
+	}
+	events.Emit("server", "slow_body_done", "", 0, nil)
+}
+
+func startHTTPServer(cfg *config.Config, srv *Server) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", newHandler(cfg))
 
-	// Start the server and listen on port 8080
-	server := &http.Server{
-		Addr: cfg.ServerAddress,
+	httpServer := &http.Server{
+		Addr:    cfg.ServerAddress,
+		Handler: mux,
 
 		// Set idle timeout to simulate server closing connection after being idle
-		IdleTimeout: cfg.ServerIdleTimeout,
+		IdleTimeout:  cfg.ServerIdleTimeout,
+		WriteTimeout: cfg.ServerWriteTimeout,
+		ConnState:    srv.ConnState,
 	}
+	srv.httpServer = httpServer
 
 	fmt.Println("Starting server on " + cfg.ServerAddress)
 
 	var err error
 	if cfg.UseTLS {
-		err = server.ListenAndServeTLS("server.crt", "server.key")
+		if cfg.TLSConfig != nil {
+			httpServer.TLSConfig = cfg.TLSConfig
+			err = httpServer.ListenAndServeTLS("", "")
+		} else {
+			err = httpServer.ListenAndServeTLS("server.crt", "server.key")
+		}
 	} else {
-		err = server.ListenAndServe()
+		err = httpServer.ListenAndServe()
 	}
-	if err != nil {
+	if err != nil && err != http.ErrServerClosed {
 		fmt.Println("Error starting server:", err)
 	}
 }
 
 var connectionCount int32
 
-func handleConnection(conn net.Conn, cfg *config.Config) {
+func handleConnection(conn net.Conn, cfg *config.Config, srv *Server) {
+	defer srv.trackClosed(conn.RemoteAddr().String())
+
 	// Increment connection counter atomically
 	currentConnection := atomic.AddInt32(&connectionCount, 1)
 
 	// Success on 1st, 3rd, ... (allows simulating retry by round tripper)
 	if cfg.ServerSuccessResponseOnFirst && currentConnection%2 == 1 {
 		// Send HTTP 200 OK response
-		sendHTTPResponse(conn, cfg)
+		sendHTTPResponse(conn, cfg, srv)
 		return
 	}
 
 	switch cfg.ServerType {
 	case config.ServerTypeMultiResponse:
-		sendMulti(conn, cfg)
+		sendMulti(conn, cfg, srv)
 		return
 	case config.ServerTypeRST:
-		sendRST(conn, cfg)
+		sendRST(conn, cfg, srv)
 		return
 	default:
 		panic("unknown server type")
 	}
 }
 
-func sendHTTPResponse(conn net.Conn, cfg *config.Config) {
+func sendHTTPResponse(conn net.Conn, cfg *config.Config, srv *Server) {
 	defer conn.Close()
 
 	if cfg.ServerSleepBeforeResponse != 0 {
@@ -117,7 +264,8 @@ func sendHTTPResponse(conn net.Conn, cfg *config.Config) {
 		"\r\n" +
 		"OK"
 
-	_, err := conn.Write([]byte(response))
+	n, err := conn.Write([]byte(response))
+	srv.trackBytesWritten(n)
 	if err != nil {
 		fmt.Println("Error writing response:", err)
 		return
@@ -128,7 +276,7 @@ func sendHTTPResponse(conn net.Conn, cfg *config.Config) {
 
 // Send one answer, then wait and then close the connection
 // the idea is that the client sends another HTTP request over this connection and then receives RST
-func sendMulti(conn net.Conn, cfg *config.Config) {
+func sendMulti(conn net.Conn, cfg *config.Config, srv *Server) {
 	defer conn.Close()
 
 	// Cheating here: not waiting for HTTP request, just assuming it comes and sending HTTP response after 100ms
@@ -141,7 +289,8 @@ func sendMulti(conn net.Conn, cfg *config.Config) {
 		"\r\n" +
 		"OK"
 
-	_, err := conn.Write([]byte(response))
+	n, err := conn.Write([]byte(response))
+	srv.trackBytesWritten(n)
 	if err != nil {
 		fmt.Println("Error writing response:", err)
 		return
@@ -150,9 +299,10 @@ func sendMulti(conn net.Conn, cfg *config.Config) {
 
 	time.Sleep(cfg.ServerMultiCloseConAfter)
 	fmt.Printf("server: closing connection\n")
+	events.Emit("server", "multi_response_closed", conn.RemoteAddr().String(), 0, nil)
 }
 
-func sendRST(conn net.Conn, cfg *config.Config) {
+func sendRST(conn net.Conn, cfg *config.Config, srv *Server) {
 	defer conn.Close()
 
 	// Get raw file descriptor from the connection
@@ -183,32 +333,73 @@ func sendRST(conn net.Conn, cfg *config.Config) {
 		return
 	}
 
+	srv.trackRST()
 	fmt.Println("server: Abruptly closed connection with RST to", conn.RemoteAddr())
+	events.Emit("server", "rst_sent", conn.RemoteAddr().String(), 0, nil)
 }
 
-func startTPCServer(cfg *config.Config) {
+func startTPCServer(cfg *config.Config, srv *Server) {
 	listener, err := net.Listen("tcp", cfg.ServerAddress)
 	if err != nil {
 		fmt.Println("Error starting bad server:", err)
 		os.Exit(1)
 	}
+	srv.listener = listener
 	defer listener.Close()
 
 	fmt.Printf("Starting Bad Server on %s\n", cfg.ServerAddress)
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
+			// Listener.Close() (graceful Shutdown) surfaces as an Accept error; stop quietly.
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
 			fmt.Println("server: Error accepting connection:", err)
 			continue
 		}
-		go handleConnection(conn, cfg)
+		srv.trackAccepted(conn.RemoteAddr().String())
+		srv.wg.Add(1)
+		go func() {
+			defer srv.wg.Done()
+			handleConnection(conn, cfg, srv)
+		}()
 	}
 }
 
-func Start(cfg *config.Config) {
-	if cfg.ServerType == config.ServerTypeHTTP {
-		startHTTPServer(cfg)
+// Start launches the server for cfg's scenario in the background and
+// returns a handle that can be used to shut it down gracefully.
+func Start(cfg *config.Config) *Server {
+	srv := newServer(cfg)
+	if cfg.ServerType == config.ServerTypeHTTP || cfg.ServerType == config.ServerTypeSlowBody {
+		go startHTTPServer(cfg, srv)
 	} else {
-		startTPCServer(cfg)
+		go startTPCServer(cfg, srv)
+	}
+	return srv
+}
+
+// StartH2C launches a cleartext HTTP/2 (h2c) server for cfg in the
+// background, serving handler through h2c.NewHandler, and returns a handle
+// wired through the same ConnState accounting and graceful Shutdown as
+// Start. handler is wrapped here rather than by the caller so that the
+// ConnState hook and the h2c upgrade wrapping stay on the same *http.Server.
+func StartH2C(cfg *config.Config, handler http.Handler) *Server {
+	srv := newServer(cfg)
+	go startH2CServer(cfg, srv, handler)
+	return srv
+}
+
+func startH2CServer(cfg *config.Config, srv *Server, handler http.Handler) {
+	httpServer := &http.Server{
+		Addr:      cfg.ServerAddress,
+		Handler:   h2c.NewHandler(handler, &http2.Server{}),
+		ConnState: srv.ConnState,
+	}
+	srv.httpServer = httpServer
+
+	fmt.Println("Starting h2c server on " + cfg.ServerAddress)
+	if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		fmt.Println("h2c: error starting server:", err)
 	}
 }
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"net/http"
 	"time"
+
+	"golang.org/x/net/http2"
 )
 
 // Config defines simulation behaviour.
@@ -12,10 +14,14 @@ type Config struct {
 	ID             string // Simulation identifier
 	Description    string // Description of the scenario
 	KeyLogFilePath string // program argument with path where to store TLS session keys
+	ScenariosDir   string // program argument: directory of *.yaml scenario files to merge in
+	ConfigFilePath string // program argument: single YAML/JSON scenario-definitions file to merge in
 
 	ServerAddress string
-	UseHTTP2      bool // Supported only by ServerTypeHTTP, enables HTTPS
-	UseTLS        bool // Enable HTTPS
+	UseHTTP2      bool       // Supported only by ServerTypeHTTP, enables HTTPS
+	HTTP2Mode     HTTP2Mode  // How UseHTTP2 negotiates the protocol; "" behaves like HTTP2ModeALPN
+	UseTLS        bool       // Enable HTTPS
+	TLS           TLSOptions // Pluggable TLS knobs (cert/key, CA, version range, ciphers, ALPN, client auth); zero value is the original behavior
 
 	ServerType        ServerType    // Type of server simulation (e.g., RST, Abrupt close)
 	ServerIdleTimeout time.Duration // Server idle timeout
@@ -28,15 +34,48 @@ type Config struct {
 	ServerSuccessResponseOnFirst bool // If the server responds with HTTP 200 OK for the first request
 	// ServerTypeMultiResponse
 	ServerMultiCloseConAfter time.Duration // Duration after which the connection is closed after the first response for ServerTypeMultiResponse
+	// ServerTypeH2GoAway
+	H2GoAwayAfterStreams int // Number of streams answered normally before GOAWAY is sent
+	// ServerTypeH2StreamReset
+	H2ResetStreamIndex int    // Request number (1-based) on which RST_STREAM is sent instead of a response
+	H2ResetErrorCode   uint32 // HTTP/2 error code carried by the RST_STREAM frame
+	// ServerTypeH2FlowControlStall
+	H2InitialWindowSize uint32 // Bytes of body written before the server stalls without a WINDOW_UPDATE
+	// ServerTypeProxy
+	ProxyReturn502AfterN    int           // Number of requests the proxy answers normally before returning 502 Bad Gateway
+	ProxyCloseTunnelAfter   time.Duration // Duration after which a CONNECT tunnel is torn down by the proxy
+	ProxyInjectAuthRequired bool          // If true, requests without Proxy-Authorization get 407
+	// ServerTypeSlowBody
+	ServerBodySize         int           // Number of body bytes to dribble out
+	ServerBodyByteInterval time.Duration // Delay between each body byte written
+	ServerWriteTimeout     time.Duration // http.Server.WriteTimeout
+
+	ClientRequestMethod         string            // HTTP request type (GET, POST, etc.)
+	ClientRequestURL            string            // URL to which the client sends the HTTP request
+	ClientRequestPath           string            // Appended to ClientRequestURL when it's built from ServerAddress (-config scenarios only)
+	ClientHeaders               map[string]string // Extra headers set on every request (-config scenarios only)
+	ClientBody                  string            // Request body sent as-is (-config scenarios only); empty means no body
+	ClientContentType           string            // Content-Type header to send alongside ClientBody
+	ExpectedStatus              int               // If set, a response with a different status code emits an "unexpected_status" event
+	ClientProxyURL              string            // If set, http.Transport.Proxy is configured to dial through this forward proxy
+	ClientWaitBeforeNextReq     time.Duration     // Time client waits before next request
+	ReqInParallel               bool              // When true, requests can be done in parallel
+	ClientIdleTimeout           time.Duration     // Time for which the TCP connection is kept in the idle pool
+	ClientMaxConnsPerHost       int               // http.Transport.MaxConnsPerHost, default 0
+	ClientTimeout               time.Duration     // Client timeout for each request
+	ClientResponseHeaderTimeout time.Duration     // http.Transport.ResponseHeaderTimeout
+	ClientReadByteInterval      time.Duration     // If set, resp.Body is read one byte at a time, this far apart
+	ReqCount                    int               // Number of requests client will make
 
-	ClientRequestMethod     string        // HTTP request type (GET, POST, etc.)
-	ClientRequestURL        string        // URL to which the client sends the HTTP request
-	ClientWaitBeforeNextReq time.Duration // Time client waits before next request
-	ReqInParallel           bool          // When true, requests can be done in parallel
-	ClientIdleTimeout       time.Duration // Time for which the TCP connection is kept in the idle pool
-	ClientMaxConnsPerHost   int           // http.Transport.MaxConnsPerHost, default 0
-	ClientTimeout           time.Duration // Client timeout for each request
-	ReqCount                int           // Number of requests client will make
+	// Load generation, set via -concurrency/-duration/-qps/-requests/-disable-keepalive.
+	// LoadConcurrency > 0 switches runSimulation from the single-client flow to runLoad.
+	LoadConcurrency      int           // Number of concurrent workers
+	LoadDuration         time.Duration // Stop after this long, 0 means no time limit (rely on LoadRequests)
+	LoadQPS              int           // Shared throttle across all workers, 0 means unthrottled
+	LoadRequests         int           // Stop after this many total requests, 0 means no limit (rely on LoadDuration)
+	LoadDisableKeepalive bool          // http.Transport.DisableKeepAlives for load workers
+
+	Expect Expect // Optional assertions checked against the recorded event log once the run finishes
 }
 
 // print outputs simulation configuration.
@@ -47,6 +86,7 @@ func (c Config) print() {
 	fmt.Printf("  Description:                  %s\n", c.Description)
 	fmt.Printf("  Server Address:               %s\n", c.ServerAddress)
 	fmt.Printf("  Use HTTP2:                    %v\n", c.UseHTTP2)
+	fmt.Printf("  HTTP2 Mode:                   %s\n", c.HTTP2Mode)
 	fmt.Printf("  Use TLS:                      %t\n", c.UseTLS)
 	fmt.Printf("  Server Idle Timeout:          %.0f sec\n", c.ServerIdleTimeout.Seconds())
 	fmt.Printf("  Server Success On First:      %v\n", c.ServerSuccessResponseOnFirst)
@@ -79,6 +119,7 @@ var simulations = Simulations{
 		ClientWaitBeforeNextReq:   1 * time.Second,
 		ClientTimeout:             10 * time.Second,
 		ReqCount:                  3,
+		Expect:                    Expect{ConnsDialed: 1, ConnsReused: 2, RequestsSucceeded: 3},
 	},
 	{
 		ID:                        "02",
@@ -147,6 +188,7 @@ var simulations = Simulations{
 		ClientWaitBeforeNextReq:      2 * time.Second,
 		ClientTimeout:                10 * time.Second,
 		ReqCount:                     3,
+		Expect:                       Expect{ConnsDialed: 3, ConnsReused: 2, RequestsSucceeded: 3, Retries: 2},
 	},
 	{
 		ID:                           "07",
@@ -162,6 +204,7 @@ var simulations = Simulations{
 		ClientWaitBeforeNextReq:      2 * time.Second,
 		ClientTimeout:                10 * time.Second,
 		ReqCount:                     3,
+		Expect:                       Expect{ConnsDialed: 2, ConnsReused: 1, RequestsSucceeded: 2, Errors: []string{"connection reset by peer"}},
 	},
 	{
 		ID:                           "08",
@@ -223,6 +266,101 @@ var simulations = Simulations{
 		ClientTimeout:           10 * time.Second,
 		ReqCount:                3,
 	},
+	{
+		ID:                      "21",
+		Description:             "HTTP2, server sends GOAWAY after 1 stream - client Transport reopens a new connection",
+		UseHTTP2:                true,
+		UseTLS:                  true,
+		ServerType:              ServerTypeH2GoAway,
+		H2GoAwayAfterStreams:    1,
+		ServerIdleTimeout:       5 * time.Second,
+		ClientRequestMethod:     http.MethodGet,
+		ClientIdleTimeout:       90 * time.Second,
+		ClientWaitBeforeNextReq: 500 * time.Millisecond,
+		ClientTimeout:           10 * time.Second,
+		ReqCount:                3,
+	},
+	{
+		ID:                      "22",
+		Description:             "HTTP2, server sends RST_STREAM on the 2nd stream - client surfaces a stream error for that request only",
+		UseHTTP2:                true,
+		UseTLS:                  true,
+		ServerType:              ServerTypeH2StreamReset,
+		H2ResetStreamIndex:      2,
+		H2ResetErrorCode:        uint32(http2.ErrCodeInternal),
+		ServerIdleTimeout:       5 * time.Second,
+		ClientRequestMethod:     http.MethodGet,
+		ClientIdleTimeout:       90 * time.Second,
+		ClientWaitBeforeNextReq: 500 * time.Millisecond,
+		ClientTimeout:           10 * time.Second,
+		ReqCount:                3,
+	},
+	{
+		ID:                  "23",
+		Description:         "HTTP2, server advertises a 1-byte flow-control window and never sends WINDOW_UPDATE - client stalls mid-body until ClientTimeout",
+		UseHTTP2:            true,
+		UseTLS:              true,
+		ServerType:          ServerTypeH2FlowControlStall,
+		H2InitialWindowSize: 1,
+		ServerIdleTimeout:   5 * time.Second,
+		ClientRequestMethod: http.MethodGet,
+		ClientIdleTimeout:   90 * time.Second,
+		ClientTimeout:       1 * time.Second,
+		ReqCount:            1,
+		Expect:              Expect{Errors: []string{"context deadline exceeded"}},
+	},
+	{
+		ID:                      "24",
+		Description:             "HTTP2 cleartext (h2c), prior knowledge - client sends the HTTP/2 preface directly over plain TCP, no TLS/ALPN involved",
+		UseHTTP2:                true,
+		HTTP2Mode:               HTTP2ModeH2CPrior,
+		ServerType:              ServerTypeHTTP,
+		ServerIdleTimeout:       5 * time.Second,
+		ClientRequestMethod:     http.MethodGet,
+		ClientIdleTimeout:       90 * time.Second,
+		ClientWaitBeforeNextReq: 1 * time.Second,
+		ClientTimeout:           10 * time.Second,
+		ReqCount:                3,
+	},
+	{
+		ID:                      "30",
+		Description:             "Forward proxy - proxy returns 502 after 1 request - client must dial a fresh connection for the retry",
+		ServerType:              ServerTypeProxy,
+		ProxyReturn502AfterN:    1,
+		ServerIdleTimeout:       5 * time.Second,
+		ClientRequestMethod:     http.MethodGet,
+		ClientRequestURL:        "http://" + originAddr + "/",
+		ClientIdleTimeout:       90 * time.Second,
+		ClientWaitBeforeNextReq: 1 * time.Second,
+		ClientTimeout:           10 * time.Second,
+		ReqCount:                3,
+		Expect:                  Expect{ConnsDialed: 1, ConnsReused: 2, RequestsSucceeded: 3},
+	},
+	{
+		ID:                    "31",
+		Description:           "Forward proxy - CONNECT tunnel closed by the proxy after 1 sec - client's tunneled connection drops mid-session",
+		ServerType:            ServerTypeProxy,
+		ProxyCloseTunnelAfter: 1 * time.Second,
+		ServerIdleTimeout:     5 * time.Second,
+		ClientRequestMethod:   http.MethodGet,
+		ClientRequestURL:      "https://" + originAddrTLS + "/",
+		ClientIdleTimeout:     90 * time.Second,
+		ClientTimeout:         10 * time.Second,
+		ReqCount:              1,
+		Expect:                Expect{ConnsDialed: 1, RequestsSucceeded: 1},
+	},
+	{
+		ID:                      "32",
+		Description:             "Forward proxy - requires Proxy-Authorization - client without credentials gets 407",
+		ServerType:              ServerTypeProxy,
+		ProxyInjectAuthRequired: true,
+		ServerIdleTimeout:       5 * time.Second,
+		ClientRequestMethod:     http.MethodGet,
+		ClientRequestURL:        "http://example.com/",
+		ClientIdleTimeout:       90 * time.Second,
+		ClientTimeout:           10 * time.Second,
+		ReqCount:                1,
+	},
 }
 
 func (s Simulations) get(id string) (c *Config, found bool) {
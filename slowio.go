@@ -0,0 +1,40 @@
+package main
+
+import (
+	"io"
+	"time"
+)
+
+// ServerTypeSlowBody writes the response headers immediately but dribbles
+// the body out one byte per ServerBodyByteInterval (see server.writeSlowBody),
+// so it can be paired with ServerWriteTimeout or ClientResponseHeaderTimeout
+// to reproduce partial-body timeout bugs.
+const ServerTypeSlowBody ServerType = iota + 7
+
+// slowReader wraps an io.ReadCloser and reads it back one byte at a time
+// with a delay between each, so a scenario can exercise a client that reads
+// the response body slower than the server writes it (e.g. against
+// ServerWriteTimeout, or a slow consumer stalling pool reuse).
+type slowReader struct {
+	r        io.ReadCloser
+	interval time.Duration
+}
+
+func newSlowReader(r io.ReadCloser, interval time.Duration) *slowReader {
+	return &slowReader{r: r, interval: interval}
+}
+
+func (s *slowReader) Read(p []byte) (int, error) {
+	if len(p) > 1 {
+		p = p[:1]
+	}
+	n, err := s.r.Read(p)
+	if n > 0 {
+		time.Sleep(s.interval)
+	}
+	return n, err
+}
+
+func (s *slowReader) Close() error {
+	return s.r.Close()
+}
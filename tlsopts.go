@@ -0,0 +1,198 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// TLSOptions exposes the TLS knobs a simulation can use to reproduce
+// real-world interop issues (mTLS, version pinning, cipher downgrades, ALPN
+// mismatches) instead of the single hardcoded server.crt/server.key pair and
+// InsecureSkipVerify:true the client used before. The zero value preserves
+// that original behavior.
+type TLSOptions struct {
+	CertFile           string // Server (or client, for mTLS) certificate; defaults to "server.crt"
+	KeyFile            string // Matching private key; defaults to "server.key"
+	CACertFile         string // CA bundle: verifies the peer's certificate (server's client cert, or client's server cert)
+	MinVersion         uint16 // tls.VersionTLS1x, 0 means Go's default
+	MaxVersion         uint16 // tls.VersionTLS1x, 0 means Go's default
+	CipherSuites       []uint16
+	ALPNProtocols      []string
+	InsecureSkipVerify bool
+	ClientAuth         tls.ClientAuthType
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"none":               tls.NoClientCert,
+	"request":            tls.RequestClientCert,
+	"require":            tls.RequireAnyClientCert,
+	"verify-if-given":    tls.VerifyClientCertIfGiven,
+	"require-and-verify": tls.RequireAndVerifyClientCert,
+}
+
+func parseTLSVersion(s string) (uint16, error) {
+	if s == "" {
+		return 0, nil
+	}
+	v, ok := tlsVersions[s]
+	if !ok {
+		return 0, fmt.Errorf("unknown TLS version %q (want one of 1.0, 1.1, 1.2, 1.3)", s)
+	}
+	return v, nil
+}
+
+func parseClientAuth(s string) (tls.ClientAuthType, error) {
+	if s == "" {
+		return tls.NoClientCert, nil
+	}
+	a, ok := clientAuthTypes[s]
+	if !ok {
+		return 0, fmt.Errorf("unknown -client-auth %q (want one of none, request, require, verify-if-given, require-and-verify)", s)
+	}
+	return a, nil
+}
+
+// parseCipherSuites looks each comma-separated name up by Go's own cipher
+// suite registry, so the accepted names match tls.CipherSuiteName exactly.
+func parseCipherSuites(csv string) ([]uint16, error) {
+	if csv == "" {
+		return nil, nil
+	}
+	byName := make(map[string]uint16)
+	for _, c := range tls.CipherSuites() {
+		byName[c.Name] = c.ID
+	}
+	for _, c := range tls.InsecureCipherSuites() {
+		byName[c.Name] = c.ID
+	}
+
+	var ids []uint16
+	for _, name := range strings.Split(csv, ",") {
+		name = strings.TrimSpace(name)
+		id, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func parseALPN(csv string) []string {
+	if csv == "" {
+		return nil
+	}
+	var protos []string
+	for _, p := range strings.Split(csv, ",") {
+		protos = append(protos, strings.TrimSpace(p))
+	}
+	return protos
+}
+
+// serverTLSConfig builds the tls.Config the simulation's listener should use,
+// loading the cert/key pair (defaulting to the repo's server.crt/server.key)
+// and, if opts.CACertFile is set, a CA pool to verify client certificates
+// against for mutual TLS.
+func serverTLSConfig(opts TLSOptions) (*tls.Config, error) {
+	certFile, keyFile := opts.CertFile, opts.KeyFile
+	if certFile == "" {
+		certFile = "server.crt"
+	}
+	if keyFile == "" {
+		keyFile = "server.key"
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load server certificate: %w", err)
+	}
+
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   opts.MinVersion,
+		MaxVersion:   opts.MaxVersion,
+		CipherSuites: opts.CipherSuites,
+		ClientAuth:   opts.ClientAuth,
+	}
+	if len(opts.ALPNProtocols) > 0 {
+		cfg.NextProtos = opts.ALPNProtocols
+	}
+
+	if opts.CACertFile != "" {
+		pool, err := loadCertPool(opts.CACertFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ClientCAs = pool
+	}
+
+	return cfg, nil
+}
+
+// clientTLSConfig builds the tls.Config the client's http.Transport should
+// use: opts.CACertFile verifies the server's certificate against a specific
+// CA instead of the system pool, and opts.CertFile/KeyFile present a client
+// certificate for scenarios exercising mutual TLS.
+func clientTLSConfig(opts TLSOptions) (*tls.Config, error) {
+	cfg := &tls.Config{
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+		MinVersion:         opts.MinVersion,
+		MaxVersion:         opts.MaxVersion,
+		CipherSuites:       opts.CipherSuites,
+	}
+	if len(opts.ALPNProtocols) > 0 {
+		cfg.NextProtos = opts.ALPNProtocols
+	}
+
+	if opts.CACertFile != "" {
+		pool, err := loadCertPool(opts.CACertFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		if opts.CertFile == "" || opts.KeyFile == "" {
+			return nil, fmt.Errorf("-cert and -key must be set together for a client certificate")
+		}
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func loadCertPool(caCertFile string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(caCertFile)
+	if err != nil {
+		return nil, fmt.Errorf("read CA cert %s: %w", caCertFile, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", caCertFile)
+	}
+	return pool, nil
+}
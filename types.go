@@ -0,0 +1,10 @@
+package main
+
+// ServerType selects which server-side behavior a simulation exercises.
+type ServerType int
+
+const (
+	ServerTypeHTTP ServerType = iota
+	ServerTypeRST
+	ServerTypeMultiResponse
+)